@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CommandEntry is one element of a YAML/JSON "commands:" list: either a
+// bare command id, or a nested execution group ({parallel: [id, ...]} or
+// {piped: [id, ...], fail_fast: true}).
+type CommandEntry struct {
+	ID       string
+	Parallel []string
+	Piped    []string
+	FailFast bool
+}
+
+// UnmarshalYAML lets CommandEntry appear as either a scalar id or a mapping
+// with a "parallel" or "piped" key.
+func (e *CommandEntry) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return value.Decode(&e.ID)
+	}
+
+	var group struct {
+		Parallel []string `yaml:"parallel"`
+		Piped    []string `yaml:"piped"`
+		FailFast bool     `yaml:"fail_fast"`
+	}
+	if err := value.Decode(&group); err != nil {
+		return err
+	}
+	e.Parallel, e.Piped, e.FailFast = group.Parallel, group.Piped, group.FailFast
+	return nil
+}
+
+// UnmarshalJSON lets CommandEntry appear as either a string id or an object
+// with a "parallel" or "piped" key.
+func (e *CommandEntry) UnmarshalJSON(data []byte) error {
+	var id string
+	if err := json.Unmarshal(data, &id); err == nil {
+		e.ID = id
+		return nil
+	}
+
+	var group struct {
+		Parallel []string `json:"parallel"`
+		Piped    []string `json:"piped"`
+		FailFast bool     `json:"fail_fast"`
+	}
+	if err := json.Unmarshal(data, &group); err != nil {
+		return err
+	}
+	e.Parallel, e.Piped, e.FailFast = group.Parallel, group.Piped, group.FailFast
+	return nil
+}
+
+// GroupMode describes how a CommandGroup's commands should be executed.
+type GroupMode int
+
+const (
+	ModeSequential GroupMode = iota
+	ModeParallel
+	ModePiped
+)
+
+// CommandGroup is one batch of commands to run together. Sequential groups
+// (the default, including the trailing fallback-sorted remainder) run one
+// at a time; Parallel groups run concurrently; Piped groups run as a strict
+// pipeline, optionally aborting the rest on first failure via FailFast.
+type CommandGroup struct {
+	Mode     GroupMode
+	Commands []map[string]interface{}
+	FailFast bool
+}
+
+// CommandGroups is the grouped-execution counterpart to the flat slice
+// sortCommandsByOrder returns.
+type CommandGroups []CommandGroup
+
+// Flatten concatenates every group's commands into a single flat slice, in
+// group order, for UIs that only need a display list rather than execution
+// semantics.
+func (groups CommandGroups) Flatten() []map[string]interface{} {
+	var flat []map[string]interface{}
+	for _, g := range groups {
+		flat = append(flat, g.Commands...)
+	}
+	return flat
+}
+
+// sortCommandsByOrderGrouped expands order.Entries into execution groups:
+// a CommandEntry with a nested {parallel: [...]} or {piped: [...]} becomes
+// its own group; runs of bare ids are batched into Sequential groups. Ids
+// missing from commandMap are skipped. Commands not mentioned anywhere in
+// order.Entries are appended as a final Sequential group, ordered the same
+// way sortCommandsByOrder would (by priority, then order.Fallback).
+func sortCommandsByOrderGrouped(commandMap map[string]map[string]interface{}, order *CommandOrder) CommandGroups {
+	if order == nil {
+		order = &CommandOrder{}
+	}
+
+	var groups CommandGroups
+	seen := make(map[string]bool)
+	var pending []map[string]interface{}
+
+	flushPending := func() {
+		if len(pending) > 0 {
+			groups = append(groups, CommandGroup{Mode: ModeSequential, Commands: pending})
+			pending = nil
+		}
+	}
+
+	collect := func(ids []string) []map[string]interface{} {
+		var cmds []map[string]interface{}
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			if cmd, ok := commandMap[id]; ok {
+				seen[id] = true
+				cmds = append(cmds, cmd)
+			}
+		}
+		return cmds
+	}
+
+	for _, entry := range order.Entries {
+		switch {
+		case len(entry.Parallel) > 0:
+			flushPending()
+			if cmds := collect(entry.Parallel); len(cmds) > 0 {
+				groups = append(groups, CommandGroup{Mode: ModeParallel, Commands: cmds})
+			}
+		case len(entry.Piped) > 0:
+			flushPending()
+			if cmds := collect(entry.Piped); len(cmds) > 0 {
+				groups = append(groups, CommandGroup{Mode: ModePiped, Commands: cmds, FailFast: entry.FailFast})
+			}
+		default:
+			if cmds := collect([]string{entry.ID}); len(cmds) > 0 {
+				pending = append(pending, cmds...)
+			}
+		}
+	}
+	flushPending()
+
+	remaining := make([]string, 0, len(commandMap))
+	for id := range commandMap {
+		if !seen[id] {
+			remaining = append(remaining, id)
+		}
+	}
+	sort.Slice(remaining, func(i, j int) bool {
+		pi := priorityFor(order.Priorities, remaining[i])
+		pj := priorityFor(order.Priorities, remaining[j])
+		if pi != pj {
+			return pi < pj
+		}
+		return fallbackLess(order, remaining[i], remaining[j])
+	})
+
+	if len(remaining) > 0 {
+		cmds := make([]map[string]interface{}, len(remaining))
+		for i, id := range remaining {
+			cmds[i] = commandMap[id]
+		}
+		groups = append(groups, CommandGroup{Mode: ModeSequential, Commands: cmds})
+	}
+
+	return groups
+}
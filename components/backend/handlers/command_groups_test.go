@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSortCommandsByOrderGrouped(t *testing.T) {
+	makeCmd := func(id string) map[string]interface{} {
+		return map[string]interface{}{"id": id, "name": id}
+	}
+
+	commandMap := map[string]map[string]interface{}{
+		"build":   makeCmd("build"),
+		"lint":    makeCmd("lint"),
+		"test":    makeCmd("test"),
+		"deploy":  makeCmd("deploy"),
+		"cleanup": makeCmd("cleanup"),
+	}
+
+	order := &CommandOrder{
+		Entries: []CommandEntry{
+			{ID: "build"},
+			{Parallel: []string{"lint", "test"}},
+			{Piped: []string{"deploy", "missing.step"}, FailFast: true},
+		},
+	}
+
+	groups := sortCommandsByOrderGrouped(commandMap, order)
+
+	if len(groups) != 4 {
+		t.Fatalf("got %d groups, want 4: %+v", len(groups), groups)
+	}
+
+	wantModes := []GroupMode{ModeSequential, ModeParallel, ModePiped, ModeSequential}
+	for i, g := range groups {
+		if g.Mode != wantModes[i] {
+			t.Errorf("group %d mode = %v, want %v", i, g.Mode, wantModes[i])
+		}
+	}
+
+	idsOf := func(g CommandGroup) []string {
+		ids := make([]string, len(g.Commands))
+		for i, cmd := range g.Commands {
+			ids[i] = cmd["id"].(string)
+		}
+		return ids
+	}
+
+	if got := idsOf(groups[0]); !reflect.DeepEqual(got, []string{"build"}) {
+		t.Errorf("sequential group 0 = %v, want [build]", got)
+	}
+	if got := idsOf(groups[1]); !reflect.DeepEqual(got, []string{"lint", "test"}) {
+		t.Errorf("parallel group = %v, want [lint test]", got)
+	}
+	if got := idsOf(groups[2]); !reflect.DeepEqual(got, []string{"deploy"}) {
+		t.Errorf("piped group = %v, want [deploy] (missing.step should be skipped)", got)
+	}
+	if !groups[2].FailFast {
+		t.Error("piped group should carry FailFast: true")
+	}
+	if got := idsOf(groups[3]); !reflect.DeepEqual(got, []string{"cleanup"}) {
+		t.Errorf("trailing fallback group = %v, want [cleanup]", got)
+	}
+
+	flat := groups.Flatten()
+	if len(flat) != 5 {
+		t.Errorf("Flatten() returned %d commands, want 5", len(flat))
+	}
+}
+
+func TestSortCommandsByOrderGrouped_FallbackAppliesToRemainder(t *testing.T) {
+	makeCmd := func(id string) map[string]interface{} {
+		return map[string]interface{}{"id": id}
+	}
+
+	commandMap := map[string]map[string]interface{}{
+		"step.10": makeCmd("step.10"),
+		"step.2":  makeCmd("step.2"),
+	}
+
+	order := &CommandOrder{Fallback: FallbackNatural}
+
+	groups := sortCommandsByOrderGrouped(commandMap, order)
+	if len(groups) != 1 {
+		t.Fatalf("got %d groups, want 1", len(groups))
+	}
+	if groups[0].Mode != ModeSequential {
+		t.Errorf("remainder group mode = %v, want ModeSequential", groups[0].Mode)
+	}
+
+	ids := []string{groups[0].Commands[0]["id"].(string), groups[0].Commands[1]["id"].(string)}
+	if !reflect.DeepEqual(ids, []string{"step.2", "step.10"}) {
+		t.Errorf("remainder order = %v, want [step.2 step.10] under natural fallback", ids)
+	}
+}
+
+func TestSortCommandsByOrderGrouped_EmptyGroupSkipped(t *testing.T) {
+	commandMap := map[string]map[string]interface{}{
+		"kept": {"id": "kept"},
+	}
+
+	order := &CommandOrder{
+		Entries: []CommandEntry{
+			{Parallel: []string{"missing.one", "missing.two"}},
+		},
+	}
+
+	groups := sortCommandsByOrderGrouped(commandMap, order)
+
+	for _, g := range groups {
+		if g.Mode == ModeParallel {
+			t.Errorf("expected the all-missing parallel group to be skipped entirely, got %+v", g)
+		}
+	}
+
+	flat := groups.Flatten()
+	if len(flat) != 1 || flat[0]["id"].(string) != "kept" {
+		t.Errorf("Flatten() = %+v, want just [kept]", flat)
+	}
+}
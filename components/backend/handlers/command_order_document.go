@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CommandOrderDocument wraps the raw yaml.Node tree of an _order.yaml file
+// so programmatic edits (e.g. a drag-and-drop reorder in the platform UI)
+// round-trip through Marshal without destroying the user's comments or key
+// ordering. This is YAML-specific: the file is always parsed as YAML, and
+// this package has no editor for _order.json or _order.toml files.
+type CommandOrderDocument struct {
+	path   string
+	root   *yaml.Node
+	doc    *yaml.Node // the mapping node at root.Content[0]
+	indent int        // source indent width, preserved on Marshal
+}
+
+// LoadCommandOrderDocument parses the _order.yaml file at path into a
+// CommandOrderDocument.
+func LoadCommandOrderDocument(path string) (*CommandOrderDocument, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(root.Content) == 0 {
+		return nil, fmt.Errorf("parsing %s: empty document", path)
+	}
+
+	doc := root.Content[0]
+	if doc.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("parsing %s: expected a mapping at the document root", path)
+	}
+
+	return &CommandOrderDocument{path: path, root: &root, doc: doc, indent: detectIndent(data)}, nil
+}
+
+// detectIndent returns the indentation width used by the first indented,
+// non-comment line in data, so Marshal can round-trip a file without
+// reflowing it to yaml.v3's default 4-space indent. It defaults to 2, this
+// repo's YAML convention, if no indented line is found.
+func detectIndent(data []byte) int {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || trimmed == line || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		return len(line) - len(trimmed)
+	}
+	return 2
+}
+
+// commandsNode returns the sequence node for the document's "commands:"
+// key, creating an empty one if it isn't present yet.
+func (d *CommandOrderDocument) commandsNode() *yaml.Node {
+	for i := 0; i+1 < len(d.doc.Content); i += 2 {
+		if d.doc.Content[i].Value == "commands" {
+			return d.doc.Content[i+1]
+		}
+	}
+
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "commands"}
+	seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	d.doc.Content = append(d.doc.Content, key, seq)
+	return seq
+}
+
+// IDs returns the command ids in the document's current order, flattening
+// any nested {parallel: [...]} / {piped: [...]} groups into the ids they
+// contain, the same way flattenEntries does for the parsed representation.
+func (d *CommandOrderDocument) IDs() []string {
+	return flattenSeqIDs(d.commandsNode())
+}
+
+// flattenSeqIDs walks seq, collecting scalar ids and recursing into any
+// parallel/piped group sequences nested inside mapping entries.
+func flattenSeqIDs(seq *yaml.Node) []string {
+	var ids []string
+	for _, n := range seq.Content {
+		if n.Kind == yaml.ScalarNode {
+			ids = append(ids, n.Value)
+			continue
+		}
+		if group := groupValueNode(n); group != nil {
+			ids = append(ids, flattenSeqIDs(group)...)
+		}
+	}
+	return ids
+}
+
+// groupValueNode returns the sequence node nested under a group mapping's
+// "parallel" or "piped" key, or nil if n isn't a recognized group entry.
+func groupValueNode(n *yaml.Node) *yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if key := n.Content[i].Value; key == "parallel" || key == "piped" {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// locateScalar searches seq, and any parallel/piped group sequences nested
+// inside it, for a scalar node with value id. It returns the sequence node
+// id lives in directly (which may be seq itself or a nested group's value
+// sequence) and its index there, or (nil, -1) if id isn't found anywhere.
+func locateScalar(seq *yaml.Node, id string) (*yaml.Node, int) {
+	for i, n := range seq.Content {
+		if n.Kind == yaml.ScalarNode && n.Value == id {
+			return seq, i
+		}
+		if group := groupValueNode(n); group != nil {
+			if parent, idx := locateScalar(group, id); parent != nil {
+				return parent, idx
+			}
+		}
+	}
+	return nil, -1
+}
+
+// pruneEmptyGroups removes any group entries in seq whose nested
+// parallel/piped sequence has been emptied out by a Move or Remove.
+func pruneEmptyGroups(seq *yaml.Node) {
+	kept := seq.Content[:0]
+	for _, n := range seq.Content {
+		if group := groupValueNode(n); group != nil && len(group.Content) == 0 {
+			continue
+		}
+		kept = append(kept, n)
+	}
+	seq.Content = kept
+}
+
+// Move relocates id to toIndex in the top-level commands list, keeping its
+// node (and any comments attached to it) intact. If id currently lives
+// inside a parallel/piped group, it's pulled out of that group first; the
+// group itself is dropped if that empties it. toIndex is clamped to the
+// top-level list's bounds. It's a no-op if id isn't present anywhere.
+func (d *CommandOrderDocument) Move(id string, toIndex int) {
+	seq := d.commandsNode()
+	parent, idx := locateScalar(seq, id)
+	if parent == nil {
+		return
+	}
+
+	node := parent.Content[idx]
+	parent.Content = append(parent.Content[:idx], parent.Content[idx+1:]...)
+	pruneEmptyGroups(seq)
+	seq.Content = insertNode(seq.Content, clampIndex(toIndex, len(seq.Content)), node)
+}
+
+// Insert adds id at atIndex in the top-level commands list, clamped to the
+// list's bounds. It's a no-op if id is already present, whether at the top
+// level or inside a group.
+func (d *CommandOrderDocument) Insert(id string, atIndex int) {
+	seq := d.commandsNode()
+	if parent, _ := locateScalar(seq, id); parent != nil {
+		return
+	}
+
+	node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: id}
+	seq.Content = insertNode(seq.Content, clampIndex(atIndex, len(seq.Content)), node)
+}
+
+// Remove deletes id from the document, whether it's a top-level entry or
+// lives inside a parallel/piped group; the group itself is dropped if that
+// empties it. It's a no-op if id isn't present anywhere.
+func (d *CommandOrderDocument) Remove(id string) {
+	seq := d.commandsNode()
+	parent, idx := locateScalar(seq, id)
+	if parent == nil {
+		return
+	}
+	parent.Content = append(parent.Content[:idx], parent.Content[idx+1:]...)
+	pruneEmptyGroups(seq)
+}
+
+// Marshal serializes the document back to YAML, preserving comments, key
+// ordering, and the source file's indentation width that Move/Insert/Remove
+// didn't touch.
+func (d *CommandOrderDocument) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(d.indent)
+	if err := enc.Encode(d.root); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func clampIndex(index, length int) int {
+	if index < 0 {
+		return 0
+	}
+	if index > length {
+		return length
+	}
+	return index
+}
+
+func insertNode(nodes []*yaml.Node, index int, node *yaml.Node) []*yaml.Node {
+	nodes = append(nodes, nil)
+	copy(nodes[index+1:], nodes[index:])
+	nodes[index] = node
+	return nodes
+}
+
+// commandOrderEditRequest is the body accepted by CommandOrderEditHandler.
+// Path is resolved relative to the handler's configured root — it is never
+// used as an absolute filesystem path.
+type commandOrderEditRequest struct {
+	Path  string `json:"path"`
+	Op    string `json:"op"` // "move", "insert", or "remove"
+	ID    string `json:"id"`
+	Index int    `json:"index"`
+}
+
+type commandOrderEditResponse struct {
+	IDs []string `json:"ids"`
+}
+
+// CommandOrderEditHandler returns an http.HandlerFunc that applies a single
+// move/insert/remove edit to an _order.yaml file under root and persists it
+// through CommandOrderDocument, so a user reordering workflow steps in the
+// platform UI keeps their inline documentation intact. The request's Path
+// is resolved relative to root and rejected if it would escape it, so a
+// caller can't point an edit at an arbitrary file on disk.
+func CommandOrderEditHandler(root string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req commandOrderEditRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("decoding request: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		path, err := resolveOrderDocumentPath(root, req.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		doc, err := LoadCommandOrderDocument(path)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("loading order document: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		switch req.Op {
+		case "move":
+			doc.Move(req.ID, req.Index)
+		case "insert":
+			doc.Insert(req.ID, req.Index)
+		case "remove":
+			doc.Remove(req.ID)
+		default:
+			http.Error(w, fmt.Sprintf("unknown op: %s", req.Op), http.StatusBadRequest)
+			return
+		}
+
+		data, err := doc.Marshal()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("marshaling order document: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			http.Error(w, fmt.Sprintf("writing order document: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(commandOrderEditResponse{IDs: doc.IDs()})
+	}
+}
+
+// resolveOrderDocumentPath joins root and rel and rejects the result unless
+// it stays within root, guarding against a request body using ".." (or an
+// absolute path) to escape the allowed directory (CWE-22).
+func resolveOrderDocumentPath(root, rel string) (string, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", fmt.Errorf("resolving root: %w", err)
+	}
+
+	absPath, err := filepath.Abs(filepath.Join(absRoot, rel))
+	if err != nil {
+		return "", fmt.Errorf("resolving path: %w", err)
+	}
+
+	if absPath != absRoot && !strings.HasPrefix(absPath, absRoot+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q escapes the allowed root", rel)
+	}
+
+	return absPath, nil
+}
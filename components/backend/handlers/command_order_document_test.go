@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeOrderDoc(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "_order.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write order file: %v", err)
+	}
+	return path
+}
+
+func TestCommandOrderDocument_IDs(t *testing.T) {
+	path := writeOrderDoc(t, `# step order
+commands:
+  - step.one
+  - step.two # keep this one last someday
+  - step.three
+`)
+
+	doc, err := LoadCommandOrderDocument(path)
+	if err != nil {
+		t.Fatalf("LoadCommandOrderDocument() failed: %v", err)
+	}
+
+	want := []string{"step.one", "step.two", "step.three"}
+	if got := doc.IDs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("IDs() = %v, want %v", got, want)
+	}
+}
+
+func TestCommandOrderDocument_IDs_FlattensGroups(t *testing.T) {
+	path := writeOrderDoc(t, `commands:
+  - step.one
+  - parallel:
+      - step.two
+      - step.three
+  - step.four
+`)
+
+	doc, err := LoadCommandOrderDocument(path)
+	if err != nil {
+		t.Fatalf("LoadCommandOrderDocument() failed: %v", err)
+	}
+
+	want := []string{"step.one", "step.two", "step.three", "step.four"}
+	if got := doc.IDs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("IDs() = %v, want %v", got, want)
+	}
+}
+
+func TestCommandOrderDocument_MoveOutOfGroup(t *testing.T) {
+	path := writeOrderDoc(t, `commands:
+  - step.one
+  - parallel:
+      - step.two
+      - step.three
+  - step.four
+`)
+
+	doc, err := LoadCommandOrderDocument(path)
+	if err != nil {
+		t.Fatalf("LoadCommandOrderDocument() failed: %v", err)
+	}
+
+	doc.Move("step.two", 0)
+	want := []string{"step.two", "step.one", "step.three", "step.four"}
+	if got := doc.IDs(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after Move: IDs() = %v, want %v", got, want)
+	}
+
+	doc.Remove("step.three")
+	want = []string{"step.two", "step.one", "step.four"}
+	if got := doc.IDs(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after Remove emptied the group: IDs() = %v, want %v", got, want)
+	}
+}
+
+func TestCommandOrderDocument_MoveInsertRemove(t *testing.T) {
+	path := writeOrderDoc(t, `commands:
+  - step.one
+  - step.two
+  - step.three
+`)
+
+	doc, err := LoadCommandOrderDocument(path)
+	if err != nil {
+		t.Fatalf("LoadCommandOrderDocument() failed: %v", err)
+	}
+
+	doc.Move("step.three", 0)
+	want := []string{"step.three", "step.one", "step.two"}
+	if got := doc.IDs(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after Move: IDs() = %v, want %v", got, want)
+	}
+
+	doc.Insert("step.four", 1)
+	want = []string{"step.three", "step.four", "step.one", "step.two"}
+	if got := doc.IDs(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after Insert: IDs() = %v, want %v", got, want)
+	}
+
+	doc.Remove("step.one")
+	want = []string{"step.three", "step.four", "step.two"}
+	if got := doc.IDs(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after Remove: IDs() = %v, want %v", got, want)
+	}
+
+	// Removing an id that isn't present, or inserting one that already is,
+	// must be a no-op.
+	doc.Remove("step.missing")
+	doc.Insert("step.four", 0)
+	if got := doc.IDs(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after no-op edits: IDs() = %v, want %v", got, want)
+	}
+}
+
+func TestCommandOrderDocument_MarshalPreservesComments(t *testing.T) {
+	path := writeOrderDoc(t, `# workflow order, please keep alphabetical within each group
+commands:
+  - step.one
+  - step.two # review gate
+`)
+
+	doc, err := LoadCommandOrderDocument(path)
+	if err != nil {
+		t.Fatalf("LoadCommandOrderDocument() failed: %v", err)
+	}
+
+	doc.Move("step.two", 0)
+
+	out, err := doc.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() failed: %v", err)
+	}
+
+	got := string(out)
+	if !strings.Contains(got, "please keep alphabetical within each group") {
+		t.Errorf("Marshal() lost the head comment:\n%s", got)
+	}
+	if !strings.Contains(got, "review gate") {
+		t.Errorf("Marshal() lost the inline comment:\n%s", got)
+	}
+}
+
+func TestCommandOrderDocument_MarshalPreservesIndent(t *testing.T) {
+	path := writeOrderDoc(t, `commands:
+  - step.one
+  - step.two
+`)
+
+	doc, err := LoadCommandOrderDocument(path)
+	if err != nil {
+		t.Fatalf("LoadCommandOrderDocument() failed: %v", err)
+	}
+
+	out, err := doc.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() failed: %v", err)
+	}
+
+	if strings.Contains(string(out), "    - step.one") {
+		t.Errorf("Marshal() reindented to 4 spaces, want the source's 2-space indent:\n%s", out)
+	}
+	if !strings.Contains(string(out), "  - step.one") {
+		t.Errorf("Marshal() lost the 2-space indented list item:\n%s", out)
+	}
+}
+
+func postCommandOrderEdit(t *testing.T, handler http.HandlerFunc, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Failed to marshal request body: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/command-order", bytes.NewReader(data))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func TestCommandOrderEditHandler_Move(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "_order.yaml"), []byte(`commands:
+  - step.one
+  - step.two
+  - step.three
+`), 0644); err != nil {
+		t.Fatalf("Failed to write order file: %v", err)
+	}
+
+	handler := CommandOrderEditHandler(root)
+	rec := postCommandOrderEdit(t, handler, map[string]interface{}{
+		"path":  "_order.yaml",
+		"op":    "move",
+		"id":    "step.three",
+		"index": 0,
+	})
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var resp commandOrderEditResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	want := []string{"step.three", "step.one", "step.two"}
+	if !reflect.DeepEqual(resp.IDs, want) {
+		t.Errorf("response IDs = %v, want %v", resp.IDs, want)
+	}
+
+	persisted, err := LoadCommandOrderDocument(filepath.Join(root, "_order.yaml"))
+	if err != nil {
+		t.Fatalf("LoadCommandOrderDocument() failed: %v", err)
+	}
+	if got := persisted.IDs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("persisted IDs = %v, want %v", got, want)
+	}
+}
+
+func TestCommandOrderEditHandler_MethodNotAllowed(t *testing.T) {
+	root := t.TempDir()
+	handler := CommandOrderEditHandler(root)
+
+	req := httptest.NewRequest(http.MethodGet, "/command-order", nil)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestCommandOrderEditHandler_UnknownOp(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "_order.yaml"), []byte(`commands:
+  - step.one
+`), 0644); err != nil {
+		t.Fatalf("Failed to write order file: %v", err)
+	}
+
+	handler := CommandOrderEditHandler(root)
+	rec := postCommandOrderEdit(t, handler, map[string]interface{}{
+		"path": "_order.yaml",
+		"op":   "shuffle",
+		"id":   "step.one",
+	})
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestCommandOrderEditHandler_RejectsPathEscapingRoot(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	secret := filepath.Join(outside, "secret.yaml")
+	if err := os.WriteFile(secret, []byte(`commands:
+  - dont.touch.me
+`), 0644); err != nil {
+		t.Fatalf("Failed to write file outside root: %v", err)
+	}
+
+	handler := CommandOrderEditHandler(root)
+
+	tests := []string{
+		"../" + filepath.Base(outside) + "/secret.yaml",
+		secret, // absolute path outside root
+	}
+
+	for _, path := range tests {
+		t.Run(path, func(t *testing.T) {
+			rec := postCommandOrderEdit(t, handler, map[string]interface{}{
+				"path": path,
+				"op":   "move",
+				"id":   "dont.touch.me",
+			})
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d; body: %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+			}
+		})
+	}
+
+	// The file outside root must be untouched.
+	data, err := os.ReadFile(secret)
+	if err != nil {
+		t.Fatalf("Failed to read file outside root: %v", err)
+	}
+	if !strings.Contains(string(data), "dont.touch.me") {
+		t.Errorf("file outside root was modified: %s", data)
+	}
+}
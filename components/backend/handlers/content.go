@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Fallback strategies for ordering commands that aren't pinned or
+// prioritized. "alphabetical" is the default and matches historical
+// behavior.
+const (
+	FallbackAlphabetical = "alphabetical"
+	FallbackNatural      = "natural"
+	FallbackMtime        = "mtime"
+)
+
+// orderFileNames lists the supported _order file names, in the precedence
+// order they're probed. Exactly one may exist in a given directory; finding
+// more than one (e.g. _order.yaml alongside _order.json) is an error rather
+// than silently picking one, since that almost always indicates a stale
+// file left behind after switching formats.
+var orderFileNames = []string{"_order.yaml", "_order.yml", "_order.json", "_order.toml"}
+
+// orderFile is the common shape every supported _order format unmarshals
+// into, so YAML, JSON, and TOML all produce identical ids and errors.
+type orderFile struct {
+	Commands   []CommandEntry `yaml:"commands" json:"commands"`
+	Priorities map[string]int `yaml:"priorities" json:"priorities" toml:"priorities"`
+	Fallback   string         `yaml:"fallback" json:"fallback" toml:"fallback"`
+}
+
+// CommandOrder carries the merged ordering directives for a command tree:
+// an explicit pinned list (run first, in the order given), a priority map
+// keyed by command id or glob pattern, and the fallback strategy used to
+// rank everything else. A priority of 0 (or no entry) is treated as
+// +Infinity, i.e. the command sorts after every explicitly prioritized one.
+type CommandOrder struct {
+	List       []string
+	Priorities map[string]int
+	Fallback   string // FallbackAlphabetical (default), FallbackNatural, or FallbackMtime
+
+	// CommandsDir is the directory containing each command's <id>.md
+	// source file, used to resolve modification times for FallbackMtime.
+	CommandsDir string
+
+	// Entries is the top-level commands list as written, including any
+	// nested {parallel: [...]} / {piped: [...]} groups. sortCommandsByOrder
+	// only looks at List (their flattened ids); sortCommandsByOrderGrouped
+	// uses Entries to preserve execution-grouping semantics.
+	Entries []CommandEntry
+}
+
+// parseCommandOrder reads the flat `commands:` list from whichever _order
+// file is present under root's .claude/commands/ directory. Nested
+// {parallel: [...]} / {piped: [...]} groups are flattened into the ids they
+// contain, in document order, since this flat-id contract predates grouped
+// execution; callers that need grouping semantics should use
+// sortCommandsByOrderGrouped instead. It returns (nil, nil) when no order
+// file is present.
+func parseCommandOrder(root string) ([]string, error) {
+	f, err := readOrderFile(filepath.Join(root, ".claude", "commands"))
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		return nil, nil
+	}
+
+	ids := flattenEntries(f.Commands)
+	if ids == nil {
+		ids = []string{}
+	}
+	return ids, nil
+}
+
+// flattenEntries expands a commands list into the bare ids it contains, in
+// document order, unwrapping any nested parallel/piped groups.
+func flattenEntries(entries []CommandEntry) []string {
+	var ids []string
+	for _, e := range entries {
+		switch {
+		case len(e.Parallel) > 0:
+			ids = append(ids, e.Parallel...)
+		case len(e.Piped) > 0:
+			ids = append(ids, e.Piped...)
+		default:
+			ids = append(ids, e.ID)
+		}
+	}
+	return ids
+}
+
+// findOrderFile looks in dir for one of orderFileNames, returning its path.
+// It returns "" if none is present, and errors if more than one is, since
+// that's almost always a stale file left behind after switching formats.
+func findOrderFile(dir string) (string, error) {
+	var found []string
+	for _, name := range orderFileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			found = append(found, path)
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return "", nil
+	case 1:
+		return found[0], nil
+	default:
+		return "", fmt.Errorf("conflicting order files in %s: %s", dir, strings.Join(found, ", "))
+	}
+}
+
+// readOrderFile loads and unmarshals whichever _order.{yaml,yml,json,toml}
+// file is present in dir, returning (nil, nil) if none exists.
+func readOrderFile(dir string) (*orderFile, error) {
+	path, err := findOrderFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := unmarshalOrderFile(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// unmarshalOrderFile routes data through the decoder matching path's
+// extension, so YAML, JSON, and TOML order files all produce the same
+// orderFile shape. TOML order files may only use bare command ids: TOML
+// arrays must be homogeneous, so there's no way to mix ids with nested
+// {parallel: [...]} / {piped: [...]} groups the way YAML and JSON can.
+func unmarshalOrderFile(path string, data []byte) (*orderFile, error) {
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		var f orderFile
+		if err := yaml.Unmarshal(data, &f); err != nil {
+			return nil, err
+		}
+		return &f, nil
+	case ".json":
+		var f orderFile
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, err
+		}
+		return &f, nil
+	case ".toml":
+		var flat struct {
+			Commands   []string       `toml:"commands"`
+			Priorities map[string]int `toml:"priorities"`
+			Fallback   string         `toml:"fallback"`
+		}
+		if _, err := toml.Decode(string(data), &flat); err != nil {
+			return nil, err
+		}
+		f := &orderFile{Priorities: flat.Priorities, Fallback: flat.Fallback}
+		for _, id := range flat.Commands {
+			f.Commands = append(f.Commands, CommandEntry{ID: id})
+		}
+		return f, nil
+	default:
+		return nil, fmt.Errorf("unsupported order file format: %s", path)
+	}
+}
+
+// LoadCommandOrder reads and merges every _order file found under
+// .claude/commands/ into a single CommandOrder: the top-level file supplies
+// the pinned list and governs ordering across folders, while each nested
+// folder's own _order file contributes priorities and a pinned list scoped
+// to commands whose id is prefixed by that folder's dot-joined path — its
+// pinned commands are appended to the merged list, in the order given,
+// right after the top-level ones. This lets a nested folder reorder its own
+// steps without touching the top-level file. Each directory may use any
+// supported format independently of its siblings.
+func LoadCommandOrder(root string) (*CommandOrder, error) {
+	commandsDir := filepath.Join(root, ".claude", "commands")
+
+	list, err := parseCommandOrder(root)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &CommandOrder{
+		List:        list,
+		Priorities:  make(map[string]int),
+		CommandsDir: commandsDir,
+	}
+
+	top, err := readOrderFile(commandsDir)
+	if err != nil {
+		return nil, err
+	}
+	if top != nil {
+		merged.Fallback = top.Fallback
+		merged.Entries = top.Commands
+		for id, pri := range top.Priorities {
+			merged.Priorities[id] = pri
+		}
+	}
+
+	err = filepath.Walk(commandsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() || path == commandsDir {
+			return nil
+		}
+
+		nested, err := readOrderFile(path)
+		if err != nil {
+			return err
+		}
+		if nested == nil {
+			return nil
+		}
+
+		rel, err := filepath.Rel(commandsDir, path)
+		if err != nil {
+			return err
+		}
+		prefix := strings.ReplaceAll(rel, string(filepath.Separator), ".") + "."
+
+		for id, pri := range nested.Priorities {
+			merged.Priorities[prefix+id] = pri
+		}
+		for _, id := range flattenEntries(nested.Commands) {
+			merged.List = append(merged.List, prefix+id)
+		}
+		return nil
+	})
+	if err != nil {
+		if os.IsNotExist(err) {
+			return merged, nil
+		}
+		return nil, err
+	}
+
+	return merged, nil
+}
+
+// sortCommandsByOrder orders the commands in commandMap according to order:
+// pinned entries in order.List come first, in the order given; everything
+// else is sorted by ascending priority from order.Priorities (1 = highest,
+// 0/unset sorts last), with order.Fallback as the final tiebreaker.
+func sortCommandsByOrder(commandMap map[string]map[string]interface{}, order *CommandOrder) []map[string]interface{} {
+	if order == nil {
+		order = &CommandOrder{}
+	}
+
+	result := make([]map[string]interface{}, 0, len(commandMap))
+	seen := make(map[string]bool)
+
+	for _, id := range order.List {
+		if seen[id] {
+			continue
+		}
+		cmd, ok := commandMap[id]
+		if !ok {
+			continue
+		}
+		seen[id] = true
+		result = append(result, cmd)
+	}
+
+	remaining := make([]string, 0, len(commandMap))
+	for id := range commandMap {
+		if !seen[id] {
+			remaining = append(remaining, id)
+		}
+	}
+
+	sort.Slice(remaining, func(i, j int) bool {
+		pi := priorityFor(order.Priorities, remaining[i])
+		pj := priorityFor(order.Priorities, remaining[j])
+		if pi != pj {
+			return pi < pj
+		}
+		return fallbackLess(order, remaining[i], remaining[j])
+	})
+
+	for _, id := range remaining {
+		result = append(result, commandMap[id])
+	}
+
+	return result
+}
+
+// priorityFor resolves the effective priority for id from a priorities map
+// that may key by exact id or by glob pattern (matched with filepath.Match
+// against the dotted id). A priority of 0, or no match at all, is treated
+// as +Infinity so the command sorts after every explicitly prioritized one.
+func priorityFor(priorities map[string]int, id string) int {
+	if p, ok := priorities[id]; ok {
+		if p == 0 {
+			return math.MaxInt
+		}
+		return p
+	}
+
+	best := math.MaxInt
+	for pattern, p := range priorities {
+		if p == 0 {
+			continue
+		}
+		if matched, _ := filepath.Match(pattern, id); matched && p < best {
+			best = p
+		}
+	}
+
+	return best
+}
+
+// fallbackLess reports whether a should sort before b under order.Fallback.
+// Unrecognized or empty strategies fall back to plain alphabetical, matching
+// historical behavior.
+func fallbackLess(order *CommandOrder, a, b string) bool {
+	switch order.Fallback {
+	case FallbackNatural:
+		return naturalLess(a, b)
+	case FallbackMtime:
+		ta, tb := commandMtime(order.CommandsDir, a), commandMtime(order.CommandsDir, b)
+		if !ta.Equal(tb) {
+			return ta.Before(tb)
+		}
+		return a < b
+	default:
+		return a < b
+	}
+}
+
+// commandMtime returns the modification time of <dir>/<id>.md, or the zero
+// time if it can't be statted (so missing sources sort first, deterministically).
+func commandMtime(dir, id string) time.Time {
+	info, err := os.Stat(filepath.Join(dir, id+".md"))
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// naturalLess compares ids the way a person would order them: runs of
+// digits compare as integers, everything else compares byte-by-byte. This
+// makes "step.2" sort before "step.10", unlike plain alphabetical order.
+func naturalLess(a, b string) bool {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		ca, cb := a[i], b[j]
+		if isDigit(ca) && isDigit(cb) {
+			na, nextI := scanNumber(a, i)
+			nb, nextJ := scanNumber(b, j)
+			if na != nb {
+				return na < nb
+			}
+			i, j = nextI, nextJ
+			continue
+		}
+		if ca != cb {
+			return ca < cb
+		}
+		i++
+		j++
+	}
+	return len(a)-i < len(b)-j
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// scanNumber reads the run of digits in s starting at start and returns its
+// integer value along with the index just past it.
+func scanNumber(s string, start int) (int, int) {
+	end := start
+	for end < len(s) && isDigit(s[end]) {
+		end++
+	}
+	n, _ := strconv.Atoi(s[start:end])
+	return n, end
+}
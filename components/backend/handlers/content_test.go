@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
 	"sort"
 	"testing"
+	"time"
 )
 
 func TestParseCommandOrder(t *testing.T) {
@@ -198,7 +200,7 @@ func TestSortCommandsByOrder(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := sortCommandsByOrder(tt.commandMap, tt.orderedIds)
+			got := sortCommandsByOrder(tt.commandMap, &CommandOrder{List: tt.orderedIds})
 
 			// Extract IDs from result
 			gotIds := make([]string, len(got))
@@ -228,7 +230,6 @@ func TestSortCommandsByOrder(t *testing.T) {
 }
 
 func TestSortCommandsByOrder_AlphabeticalRemaining(t *testing.T) {
-	// Test that unordered commands are sorted alphabetically
 	makeCmd := func(id string) map[string]interface{} {
 		return map[string]interface{}{
 			"id":   id,
@@ -236,39 +237,126 @@ func TestSortCommandsByOrder_AlphabeticalRemaining(t *testing.T) {
 		}
 	}
 
-	commandMap := map[string]map[string]interface{}{
-		"zebra":   makeCmd("zebra"),
-		"alpha":   makeCmd("alpha"),
-		"charlie": makeCmd("charlie"),
-		"bravo":   makeCmd("bravo"),
-	}
+	t.Run("plain alphabetical fallback", func(t *testing.T) {
+		commandMap := map[string]map[string]interface{}{
+			"zebra":   makeCmd("zebra"),
+			"alpha":   makeCmd("alpha"),
+			"charlie": makeCmd("charlie"),
+			"bravo":   makeCmd("bravo"),
+		}
 
-	orderedIds := []string{"bravo"} // Only bravo is ordered
+		orderedIds := []string{"bravo"} // Only bravo is ordered
 
-	result := sortCommandsByOrder(commandMap, orderedIds)
+		result := sortCommandsByOrder(commandMap, &CommandOrder{List: orderedIds})
 
-	// Extract IDs
-	gotIds := make([]string, len(result))
-	for i, cmd := range result {
-		gotIds[i] = cmd["id"].(string)
-	}
+		gotIds := make([]string, len(result))
+		for i, cmd := range result {
+			gotIds[i] = cmd["id"].(string)
+		}
 
-	// Expected: bravo first (ordered), then alpha, charlie, zebra (alphabetical)
-	want := []string{"bravo", "alpha", "charlie", "zebra"}
+		// Expected: bravo first (ordered), then alpha, charlie, zebra (alphabetical)
+		want := []string{"bravo", "alpha", "charlie", "zebra"}
 
-	if !reflect.DeepEqual(gotIds, want) {
-		t.Errorf("sortCommandsByOrder() alphabetical ordering failed: got %v, want %v", gotIds, want)
-	}
+		if !reflect.DeepEqual(gotIds, want) {
+			t.Errorf("sortCommandsByOrder() alphabetical ordering failed: got %v, want %v", gotIds, want)
+		}
 
-	// Verify the remaining commands after "bravo" are sorted
-	remaining := gotIds[1:] // Skip first ordered command
-	sortedRemaining := make([]string, len(remaining))
-	copy(sortedRemaining, remaining)
-	sort.Strings(sortedRemaining)
+		// Verify the remaining commands after "bravo" are sorted
+		remaining := gotIds[1:]
+		sortedRemaining := make([]string, len(remaining))
+		copy(sortedRemaining, remaining)
+		sort.Strings(sortedRemaining)
 
-	if !reflect.DeepEqual(remaining, sortedRemaining) {
-		t.Errorf("Remaining commands not alphabetically sorted: got %v, want %v", remaining, sortedRemaining)
-	}
+		if !reflect.DeepEqual(remaining, sortedRemaining) {
+			t.Errorf("Remaining commands not alphabetically sorted: got %v, want %v", remaining, sortedRemaining)
+		}
+	})
+
+	t.Run("natural fallback orders step.1 through step.12 numerically", func(t *testing.T) {
+		commandMap := make(map[string]map[string]interface{})
+		// Insertion order deliberately scrambled relative to both
+		// alphabetical and numeric order.
+		for _, n := range []int{1, 10, 2, 12, 3, 11, 4, 9, 5, 8, 6, 7} {
+			id := fmt.Sprintf("step.%d", n)
+			commandMap[id] = makeCmd(id)
+		}
+
+		result := sortCommandsByOrder(commandMap, &CommandOrder{Fallback: FallbackNatural})
+
+		gotIds := make([]string, len(result))
+		for i, cmd := range result {
+			gotIds[i] = cmd["id"].(string)
+		}
+
+		want := make([]string, 12)
+		for n := 1; n <= 12; n++ {
+			want[n-1] = fmt.Sprintf("step.%d", n)
+		}
+
+		if !reflect.DeepEqual(gotIds, want) {
+			t.Errorf("natural fallback = %v, want %v", gotIds, want)
+		}
+
+		seen := make(map[string]bool)
+		for _, id := range gotIds {
+			if seen[id] {
+				t.Errorf("natural fallback returned duplicate: %s", id)
+			}
+			seen[id] = true
+		}
+	})
+
+	t.Run("plain alphabetical fallback sorts step.10 before step.2", func(t *testing.T) {
+		commandMap := map[string]map[string]interface{}{
+			"step.2":  makeCmd("step.2"),
+			"step.10": makeCmd("step.10"),
+		}
+
+		result := sortCommandsByOrder(commandMap, &CommandOrder{})
+
+		gotIds := make([]string, len(result))
+		for i, cmd := range result {
+			gotIds[i] = cmd["id"].(string)
+		}
+
+		want := []string{"step.10", "step.2"}
+		if !reflect.DeepEqual(gotIds, want) {
+			t.Errorf("alphabetical fallback = %v, want %v", gotIds, want)
+		}
+	})
+
+	t.Run("mtime fallback orders by file modification time", func(t *testing.T) {
+		dir := t.TempDir()
+		ids := []string{"step.c", "step.a", "step.b"}
+		for i, id := range ids {
+			path := filepath.Join(dir, id+".md")
+			if err := os.WriteFile(path, []byte("# "+id), 0644); err != nil {
+				t.Fatalf("Failed to write command file: %v", err)
+			}
+			mtime := time.Now().Add(time.Duration(i) * time.Minute)
+			if err := os.Chtimes(path, mtime, mtime); err != nil {
+				t.Fatalf("Failed to set mtime: %v", err)
+			}
+		}
+
+		commandMap := map[string]map[string]interface{}{
+			"step.c": makeCmd("step.c"),
+			"step.a": makeCmd("step.a"),
+			"step.b": makeCmd("step.b"),
+		}
+
+		result := sortCommandsByOrder(commandMap, &CommandOrder{Fallback: FallbackMtime, CommandsDir: dir})
+
+		gotIds := make([]string, len(result))
+		for i, cmd := range result {
+			gotIds[i] = cmd["id"].(string)
+		}
+
+		want := []string{"step.c", "step.a", "step.b"} // oldest mtime first
+		if !reflect.DeepEqual(gotIds, want) {
+			t.Errorf("mtime fallback = %v, want %v", gotIds, want)
+		}
+	})
 }
 
 func TestCommandOrderIntegration(t *testing.T) {
@@ -340,7 +428,7 @@ description: Test command
 	}
 
 	// Sort commands
-	result := sortCommandsByOrder(commandMap, orderedIds)
+	result := sortCommandsByOrder(commandMap, &CommandOrder{List: orderedIds})
 
 	// Extract IDs
 	gotIds := make([]string, len(result))
@@ -363,3 +451,306 @@ description: Test command
 		t.Errorf("Integration test failed:\ngot  %v\nwant %v", gotIds, want)
 	}
 }
+
+func TestParseCommandOrder_JSON(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "test-command-order-json-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	commandsDir := filepath.Join(tmpDir, ".claude", "commands")
+	if err := os.MkdirAll(commandsDir, 0755); err != nil {
+		t.Fatalf("Failed to create commands dir: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		jsonContent string
+		wantIds     []string
+		wantErr     bool
+	}{
+		{
+			name:        "valid order file",
+			jsonContent: `{"commands": ["command.one", "command.two", "command.three"]}`,
+			wantIds:     []string{"command.one", "command.two", "command.three"},
+		},
+		{
+			name:        "empty commands list",
+			jsonContent: `{"commands": []}`,
+			wantIds:     []string{},
+		},
+		{
+			name:        "malformed json",
+			jsonContent: `{"commands": [`,
+			wantIds:     nil,
+			wantErr:     true,
+		},
+		{
+			name:        "wrong structure",
+			jsonContent: `{"wrongkey": ["command.one"]}`,
+			wantIds:     []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orderFile := filepath.Join(commandsDir, "_order.json")
+			os.Remove(orderFile)
+
+			if err := os.WriteFile(orderFile, []byte(tt.jsonContent), 0644); err != nil {
+				t.Fatalf("Failed to write test file: %v", err)
+			}
+
+			gotIds, err := parseCommandOrder(tmpDir)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseCommandOrder() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(gotIds, tt.wantIds) {
+				t.Errorf("parseCommandOrder() = %v, want %v", gotIds, tt.wantIds)
+			}
+		})
+	}
+}
+
+func TestParseCommandOrder_TOML(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "test-command-order-toml-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	commandsDir := filepath.Join(tmpDir, ".claude", "commands")
+	if err := os.MkdirAll(commandsDir, 0755); err != nil {
+		t.Fatalf("Failed to create commands dir: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		tomlContent string
+		wantIds     []string
+		wantErr     bool
+	}{
+		{
+			name:        "valid order file",
+			tomlContent: `commands = ["command.one", "command.two", "command.three"]`,
+			wantIds:     []string{"command.one", "command.two", "command.three"},
+		},
+		{
+			name:        "empty commands list",
+			tomlContent: `commands = []`,
+			wantIds:     []string{},
+		},
+		{
+			name:        "malformed toml",
+			tomlContent: `commands = [`,
+			wantIds:     nil,
+			wantErr:     true,
+		},
+		{
+			name:        "wrong structure",
+			tomlContent: `wrongkey = ["command.one"]`,
+			wantIds:     []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orderFile := filepath.Join(commandsDir, "_order.toml")
+			os.Remove(orderFile)
+
+			if err := os.WriteFile(orderFile, []byte(tt.tomlContent), 0644); err != nil {
+				t.Fatalf("Failed to write test file: %v", err)
+			}
+
+			gotIds, err := parseCommandOrder(tmpDir)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseCommandOrder() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(gotIds, tt.wantIds) {
+				t.Errorf("parseCommandOrder() = %v, want %v", gotIds, tt.wantIds)
+			}
+		})
+	}
+}
+
+func TestParseCommandOrder_ConflictingFormats(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "test-command-order-conflict-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	commandsDir := filepath.Join(tmpDir, ".claude", "commands")
+	if err := os.MkdirAll(commandsDir, 0755); err != nil {
+		t.Fatalf("Failed to create commands dir: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(commandsDir, "_order.yaml"), []byte(`commands: [command.one]`), 0644); err != nil {
+		t.Fatalf("Failed to write yaml order file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(commandsDir, "_order.json"), []byte(`{"commands": ["command.one"]}`), 0644); err != nil {
+		t.Fatalf("Failed to write json order file: %v", err)
+	}
+
+	if _, err := parseCommandOrder(tmpDir); err == nil {
+		t.Error("parseCommandOrder() expected an error for conflicting order files, got nil")
+	}
+}
+
+func TestSortCommandsByOrder_Priorities(t *testing.T) {
+	makeCmd := func(id string) map[string]interface{} {
+		return map[string]interface{}{"id": id, "name": id}
+	}
+
+	commandMap := map[string]map[string]interface{}{
+		"cmd.a": makeCmd("cmd.a"),
+		"cmd.b": makeCmd("cmd.b"),
+		"cmd.c": makeCmd("cmd.c"),
+		"cmd.d": makeCmd("cmd.d"),
+	}
+
+	tests := []struct {
+		name       string
+		orderedIds []string
+		priorities map[string]int
+		want       []string
+	}{
+		{
+			name:       "priorities rank ascending, 0 runs last",
+			priorities: map[string]int{"cmd.b": 1, "cmd.c": 2, "cmd.d": 0},
+			want:       []string{"cmd.b", "cmd.c", "cmd.a", "cmd.d"},
+		},
+		{
+			name:       "unset priority sorts with priority-0 entries",
+			priorities: map[string]int{"cmd.a": 1},
+			want:       []string{"cmd.a", "cmd.b", "cmd.c", "cmd.d"},
+		},
+		{
+			name:       "pinned list wins over priorities",
+			orderedIds: []string{"cmd.d"},
+			priorities: map[string]int{"cmd.a": 1, "cmd.d": 1},
+			want:       []string{"cmd.d", "cmd.a", "cmd.b", "cmd.c"},
+		},
+		{
+			name:       "glob pattern priority",
+			priorities: map[string]int{"cmd.*": 1, "cmd.d": 2},
+			want:       []string{"cmd.a", "cmd.b", "cmd.c", "cmd.d"},
+		},
+		{
+			name:       "explicit zero wins over matching glob",
+			priorities: map[string]int{"cmd.d": 0, "cmd.*": 1},
+			want:       []string{"cmd.a", "cmd.b", "cmd.c", "cmd.d"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := &CommandOrder{List: tt.orderedIds, Priorities: tt.priorities}
+			got := sortCommandsByOrder(commandMap, order)
+
+			gotIds := make([]string, len(got))
+			for i, cmd := range got {
+				gotIds[i] = cmd["id"].(string)
+			}
+
+			if !reflect.DeepEqual(gotIds, tt.want) {
+				t.Errorf("sortCommandsByOrder() = %v, want %v", gotIds, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadCommandOrder_HierarchicalMerge(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "test-command-order-hierarchy-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	commandsDir := filepath.Join(tmpDir, ".claude", "commands")
+	releaseDir := filepath.Join(commandsDir, "release")
+	if err := os.MkdirAll(releaseDir, 0755); err != nil {
+		t.Fatalf("Failed to create nested commands dir: %v", err)
+	}
+
+	topOrder := `commands:
+  - release.deploy
+priorities:
+  feature.implement: 1
+`
+	if err := os.WriteFile(filepath.Join(commandsDir, "_order.yaml"), []byte(topOrder), 0644); err != nil {
+		t.Fatalf("Failed to write top-level order file: %v", err)
+	}
+
+	nestedOrder := `priorities:
+  build: 1
+  test: 2
+`
+	if err := os.WriteFile(filepath.Join(releaseDir, "_order.yaml"), []byte(nestedOrder), 0644); err != nil {
+		t.Fatalf("Failed to write nested order file: %v", err)
+	}
+
+	order, err := LoadCommandOrder(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadCommandOrder() failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(order.List, []string{"release.deploy"}) {
+		t.Errorf("LoadCommandOrder().List = %v, want %v", order.List, []string{"release.deploy"})
+	}
+
+	wantPriorities := map[string]int{
+		"feature.implement": 1,
+		"release.build":     1,
+		"release.test":      2,
+	}
+	if !reflect.DeepEqual(order.Priorities, wantPriorities) {
+		t.Errorf("LoadCommandOrder().Priorities = %v, want %v", order.Priorities, wantPriorities)
+	}
+}
+
+func TestLoadCommandOrder_HierarchicalMerge_NestedPinnedList(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "test-command-order-nested-pins-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	commandsDir := filepath.Join(tmpDir, ".claude", "commands")
+	releaseDir := filepath.Join(commandsDir, "release")
+	if err := os.MkdirAll(releaseDir, 0755); err != nil {
+		t.Fatalf("Failed to create nested commands dir: %v", err)
+	}
+
+	topOrder := `commands:
+  - feature.implement
+`
+	if err := os.WriteFile(filepath.Join(commandsDir, "_order.yaml"), []byte(topOrder), 0644); err != nil {
+		t.Fatalf("Failed to write top-level order file: %v", err)
+	}
+
+	nestedOrder := `commands:
+  - build
+  - test
+`
+	if err := os.WriteFile(filepath.Join(releaseDir, "_order.yaml"), []byte(nestedOrder), 0644); err != nil {
+		t.Fatalf("Failed to write nested order file: %v", err)
+	}
+
+	order, err := LoadCommandOrder(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadCommandOrder() failed: %v", err)
+	}
+
+	want := []string{"feature.implement", "release.build", "release.test"}
+	if !reflect.DeepEqual(order.List, want) {
+		t.Errorf("LoadCommandOrder().List = %v, want %v", order.List, want)
+	}
+}